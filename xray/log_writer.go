@@ -0,0 +1,263 @@
+package xray
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"x-ui/logger"
+)
+
+const logRingBufferSize = 2048
+
+// LogEvent is a single parsed line of xray's access or error log.
+type LogEvent struct {
+	Timestamp   time.Time
+	Level       string
+	SourceIP    string
+	Email       string
+	Destination string
+	InboundTag  string
+	Outbound    string
+	Raw         string
+}
+
+// LogFilter narrows down which LogEvents TailAccessLog/SubscribeLogs return.
+// Zero-value fields are ignored.
+type LogFilter struct {
+	Email      string
+	InboundTag string
+	Level      string
+}
+
+func (f LogFilter) matches(e LogEvent) bool {
+	if f.Email != "" && e.Email != f.Email {
+		return false
+	}
+	if f.InboundTag != "" && e.InboundTag != f.InboundTag {
+		return false
+	}
+	if f.Level != "" && e.Level != f.Level {
+		return false
+	}
+	return true
+}
+
+// LogWriter implements io.Writer for xray-core's stdout/stderr, keeping the
+// last line for GetResult() while also parsing each line into a structured
+// LogEvent, appended to an in-memory ring buffer and fanned out to
+// subscribers.
+type LogWriter struct {
+	lastLine string
+
+	mutex       sync.Mutex
+	ring        []LogEvent
+	ringHead    int
+	ringFilled  bool
+	subscribers map[chan LogEvent]LogFilter
+}
+
+// NewLogWriter creates a LogWriter ready to be used as cmd.Stdout/cmd.Stderr.
+func NewLogWriter() *LogWriter {
+	return &LogWriter{
+		ring:        make([]LogEvent, logRingBufferSize),
+		subscribers: make(map[chan LogEvent]LogFilter),
+	}
+}
+
+func (w *LogWriter) Write(m []byte) (n int, err error) {
+	n = len(m)
+	lines := strings.Split(strings.TrimRight(string(m), "\n"), "\n")
+	for _, line := range lines {
+		if line == "" {
+			continue
+		}
+		w.lastLine = line
+		w.publish(parseLogLine(line))
+	}
+	return n, nil
+}
+
+func (w *LogWriter) publish(event LogEvent) {
+	w.mutex.Lock()
+	w.ring[w.ringHead] = event
+	w.ringHead = (w.ringHead + 1) % len(w.ring)
+	if w.ringHead == 0 {
+		w.ringFilled = true
+	}
+	subscribers := make(map[chan LogEvent]LogFilter, len(w.subscribers))
+	for ch, filter := range w.subscribers {
+		subscribers[ch] = filter
+	}
+	w.mutex.Unlock()
+
+	for ch, filter := range subscribers {
+		if !filter.matches(event) {
+			continue
+		}
+		select {
+		case ch <- event:
+		default:
+			logger.Warning("Dropping xray log event, subscriber channel full")
+		}
+	}
+}
+
+// recent returns the buffered events in chronological order that match
+// filter.
+func (w *LogWriter) recent(filter LogFilter) []LogEvent {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	return w.recentLocked(filter)
+}
+
+// recentLocked is recent with w.mutex already held, so SubscribeLogs can
+// read the backlog and register its subscriber as one atomic step, with no
+// window in which a live event could be missed or delivered twice.
+func (w *LogWriter) recentLocked(filter LogFilter) []LogEvent {
+	var ordered []LogEvent
+	if w.ringFilled {
+		ordered = append(ordered, w.ring[w.ringHead:]...)
+	}
+	ordered = append(ordered, w.ring[:w.ringHead]...)
+
+	events := make([]LogEvent, 0, len(ordered))
+	for _, e := range ordered {
+		if e.Raw == "" {
+			continue
+		}
+		if filter.matches(e) {
+			events = append(events, e)
+		}
+	}
+	return events
+}
+
+// parseLogLine recognizes xray's plain-text access/error log format as well
+// as its JSON log format (when the user configures "log.format": "json" in
+// the xray config).
+func parseLogLine(line string) LogEvent {
+	if strings.HasPrefix(strings.TrimSpace(line), "{") {
+		if event, ok := parseJSONLogLine(line); ok {
+			return event
+		}
+	}
+	return parsePlainLogLine(line)
+}
+
+func parseJSONLogLine(line string) (LogEvent, bool) {
+	var fields map[string]any
+	if err := json.Unmarshal([]byte(line), &fields); err != nil {
+		return LogEvent{}, false
+	}
+
+	event := LogEvent{Raw: line}
+	if ts, ok := fields["time"].(string); ok {
+		if t, err := time.Parse(time.RFC3339, ts); err == nil {
+			event.Timestamp = t
+		}
+	}
+	if event.Timestamp.IsZero() {
+		event.Timestamp = time.Now()
+	}
+	event.Level, _ = fields["level"].(string)
+	event.SourceIP, _ = fields["from"].(string)
+	event.Email, _ = fields["email"].(string)
+	event.Destination, _ = fields["to"].(string)
+	event.InboundTag, _ = fields["inbound"].(string)
+	event.Outbound, _ = fields["outbound"].(string)
+	return event, true
+}
+
+// parsePlainLogLine handles xray's default text access-log line, e.g.:
+// 2023/10/12 10:00:00 127.0.0.1:51820 accepted tcp:example.com:443 [api -> direct] email: user@example.com
+func parsePlainLogLine(line string) LogEvent {
+	event := LogEvent{Raw: line, Timestamp: time.Now()}
+
+	rest := line
+	if len(line) >= 19 {
+		if t, err := time.ParseInLocation("2006/01/02 15:04:05", line[:19], time.Local); err == nil {
+			event.Timestamp = t
+			rest = strings.TrimSpace(line[19:])
+		}
+	}
+
+	fields := strings.Fields(rest)
+	for i := 0; i < len(fields); i++ {
+		field := fields[i]
+		switch {
+		case strings.Contains(field, ":") && event.SourceIP == "" && i == 0:
+			event.SourceIP = field
+		case field == "accepted" || field == "rejected" || field == "failed":
+			event.Level = field
+		case strings.HasPrefix(field, "["):
+			// The tag pair is split across fields, e.g. "[api", "->", "direct]".
+			end := i
+			for end < len(fields)-1 && !strings.HasSuffix(fields[end], "]") {
+				end++
+			}
+			tags := strings.Trim(strings.Join(fields[i:end+1], " "), "[]")
+			i = end
+			parts := strings.SplitN(tags, "->", 2)
+			if len(parts) == 2 {
+				event.InboundTag = strings.TrimSpace(parts[0])
+				event.Outbound = strings.TrimSpace(parts[1])
+			}
+		case strings.HasPrefix(field, "email:"):
+			event.Email = strings.TrimPrefix(field, "email:")
+			if event.Email == "" && i+1 < len(fields) {
+				i++
+				event.Email = fields[i]
+			}
+		}
+	}
+	if event.Destination == "" && len(fields) > 2 {
+		event.Destination = fields[2]
+	}
+	return event
+}
+
+// TailAccessLog reads the xray access log file configured via
+// GetAccessLogPath, returning parsed events at or after since that match
+// filter. Each call reads the file at that path from its start; no read
+// offset is kept between calls, and rotated files (e.g. access.log.1) are
+// not read.
+func (p *Process) TailAccessLog(since time.Time, filter LogFilter) ([]LogEvent, error) {
+	path, err := GetAccessLogPath()
+	if err != nil {
+		return nil, err
+	}
+	if path == "" || path == "none" {
+		return nil, nil
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var events []LogEvent
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" {
+			continue
+		}
+		event := parseLogLine(line)
+		if event.Timestamp.Before(since) {
+			continue
+		}
+		if filter.matches(event) {
+			events = append(events, event)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return events, err
+	}
+	return events, nil
+}
@@ -0,0 +1,90 @@
+package xray
+
+import "testing"
+
+func TestParsePlainLogLine(t *testing.T) {
+	line := "2023/10/12 10:00:00 127.0.0.1:51820 accepted tcp:example.com:443 [api -> direct] email: user@example.com"
+	event := parsePlainLogLine(line)
+
+	if event.Raw != line {
+		t.Errorf("Raw = %q, want %q", event.Raw, line)
+	}
+	if event.SourceIP != "127.0.0.1:51820" {
+		t.Errorf("SourceIP = %q, want 127.0.0.1:51820", event.SourceIP)
+	}
+	if event.Level != "accepted" {
+		t.Errorf("Level = %q, want accepted", event.Level)
+	}
+	if event.InboundTag != "api" || event.Outbound != "direct" {
+		t.Errorf("InboundTag/Outbound = %q/%q, want api/direct", event.InboundTag, event.Outbound)
+	}
+	if event.Email != "user@example.com" {
+		t.Errorf("Email = %q, want user@example.com", event.Email)
+	}
+	if event.Destination != "tcp:example.com:443" {
+		t.Errorf("Destination = %q, want tcp:example.com:443", event.Destination)
+	}
+}
+
+func TestParseJSONLogLine(t *testing.T) {
+	line := `{"time":"2023-10-12T10:00:00Z","level":"accepted","from":"127.0.0.1:51820","to":"tcp:example.com:443","inbound":"api","outbound":"direct","email":"user@example.com"}`
+
+	event, ok := parseJSONLogLine(line)
+	if !ok {
+		t.Fatal("parseJSONLogLine() ok = false, want true")
+	}
+	if event.Level != "accepted" || event.Email != "user@example.com" || event.InboundTag != "api" || event.Outbound != "direct" {
+		t.Errorf("event = %+v, unexpected fields", event)
+	}
+}
+
+func TestParseLogLineDispatch(t *testing.T) {
+	plain := "2023/10/12 10:00:00 127.0.0.1:51820 accepted tcp:example.com:443 [api -> direct] email: user@example.com"
+	if event := parseLogLine(plain); event.Email != "user@example.com" {
+		t.Errorf("parseLogLine(plain) email = %q, want user@example.com", event.Email)
+	}
+
+	jsonLine := `{"time":"2023-10-12T10:00:00Z","level":"rejected","email":"user@example.com"}`
+	if event := parseLogLine(jsonLine); event.Level != "rejected" {
+		t.Errorf("parseLogLine(json) level = %q, want rejected", event.Level)
+	}
+}
+
+func TestLogWriterRecentReturnsChronologicalBacklog(t *testing.T) {
+	w := NewLogWriter()
+	w.publish(LogEvent{Raw: "one", Email: "user@example.com"})
+	w.publish(LogEvent{Raw: "two", Email: "other@example.com"})
+	w.publish(LogEvent{Raw: "three", Email: "user@example.com"})
+
+	all := w.recent(LogFilter{})
+	if len(all) != 3 || all[0].Raw != "one" || all[2].Raw != "three" {
+		t.Fatalf("recent(empty) = %+v, want [one two three]", all)
+	}
+
+	filtered := w.recent(LogFilter{Email: "user@example.com"})
+	if len(filtered) != 2 || filtered[0].Raw != "one" || filtered[1].Raw != "three" {
+		t.Errorf("recent(email filter) = %+v, want [one three]", filtered)
+	}
+}
+
+func TestLogFilterMatches(t *testing.T) {
+	event := LogEvent{Email: "user@example.com", InboundTag: "api", Level: "accepted"}
+
+	cases := []struct {
+		name   string
+		filter LogFilter
+		want   bool
+	}{
+		{"empty filter matches anything", LogFilter{}, true},
+		{"matching email", LogFilter{Email: "user@example.com"}, true},
+		{"mismatching email", LogFilter{Email: "other@example.com"}, false},
+		{"matching inbound", LogFilter{InboundTag: "api"}, true},
+		{"mismatching level", LogFilter{Level: "rejected"}, false},
+	}
+
+	for _, c := range cases {
+		if got := c.filter.matches(event); got != c.want {
+			t.Errorf("%s: matches() = %v, want %v", c.name, got, c.want)
+		}
+	}
+}
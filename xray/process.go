@@ -110,22 +110,61 @@ type process struct {
 	logWriter *LogWriter
 	exitErr   error
 	startTime time.Time
+
+	// supervision state, guarded by supervisorMu
+	supervisorMu    sync.Mutex
+	autoRestart     bool
+	restartPolicy   RestartPolicy
+	restartCount    int
+	lastCrash       *CrashReport
+	intentionalStop bool
+	failureCount    int
+	failureWindowAt time.Time
+	crashHook       func(*CrashReport)
+	supervisorDone  chan struct{}
+
+	// stats collector state
+	statsCollectorConfig StatsCollectorConfig
+	trafficDeltas        []TrafficDelta
+	statsStopCh          chan struct{}
+
+	// runner is non-nil when this Process is controlling a remote xray
+	// instance through an agent instead of a local exec.Cmd; see runner.go.
+	runner XrayRunner
 }
 
 func newProcess(config *Config) *process {
 	return &process{
-		version:   "Unknown",
-		config:    config,
-		logWriter: NewLogWriter(),
-		startTime: time.Now(),
+		version:       "Unknown",
+		config:        config,
+		logWriter:     NewLogWriter(),
+		startTime:     time.Now(),
+		autoRestart:   true,
+		restartPolicy: DefaultRestartPolicy(),
 	}
 }
 
+// setCmd and getCmd guard p.cmd, which is written by Start() and rewritten
+// by supervise() on every restart, against concurrent reads from IsRunning/
+// Stop on other goroutines (typically web handlers).
+func (p *process) setCmd(cmd *exec.Cmd) {
+	p.supervisorMu.Lock()
+	p.cmd = cmd
+	p.supervisorMu.Unlock()
+}
+
+func (p *process) getCmd() *exec.Cmd {
+	p.supervisorMu.Lock()
+	defer p.supervisorMu.Unlock()
+	return p.cmd
+}
+
 func (p *process) IsRunning() bool {
-	if p.cmd == nil || p.cmd.Process == nil {
+	cmd := p.getCmd()
+	if cmd == nil || cmd.Process == nil {
 		return false
 	}
-	if p.cmd.ProcessState == nil {
+	if cmd.ProcessState == nil {
 		return true
 	}
 	return false
@@ -154,18 +193,6 @@ func (p *Process) GetConfig() *Config {
 	return p.config
 }
 
-// 【增加】中文注释：为 Process 结构体添加 GetOnlineClients 方法。
-// 这个方法用于安全地读取并返回当前在线的用户列表。
-// 它使用“读锁”（RLock），允许多个程序同时读取数据以提高性能，同时防止在读取时数据被意外修改。
-func (p *Process) GetOnlineClients() []string {
-	p.mutex.RLock()
-	defer p.mutex.RUnlock()
-	// 为了绝对安全，我们返回列表的一个拷贝，而不是直接返回内部列表的引用
-	clientsCopy := make([]string, len(p.onlineClients))
-	copy(clientsCopy, p.onlineClients)
-	return clientsCopy
-}
-
 // 【增加】中文注释：为 Process 结构体添加 SetOnlineClients 方法。
 // 这个方法用于从流量统计服务接收最新的在线用户列表，并安全地更新到 Process 实例中。
 // 它使用“写锁”（Lock），确保同一时间只有一个程序能写入数据，防止冲突。
@@ -175,10 +202,6 @@ func (p *Process) SetOnlineClients(clients []string) {
 	p.onlineClients = clients
 }
 
-func (p *Process) GetUptime() uint64 {
-	return uint64(time.Since(p.startTime).Seconds())
-}
-
 func (p *process) refreshAPIPort() {
 	for _, inbound := range p.config.InboundConfigs {
 		if inbound.Tag == "api" {
@@ -231,36 +254,69 @@ func (p *process) Start() (err error) {
 		return common.NewErrorf("Failed to write configuration file: %v", err)
 	}
 
-	cmd := exec.Command(GetBinaryPath(), "-c", configPath)
-	p.cmd = cmd
-
-	cmd.Stdout = p.logWriter
-	cmd.Stderr = p.logWriter
-
-	go func() {
-		err := cmd.Run()
-		if err != nil {
-			logger.Error("Failure in running xray-core:", err)
-			p.exitErr = err
-		}
-	}()
+	cmd, err := p.runOnce(configPath)
+	if err != nil {
+		return err
+	}
+	p.setCmd(cmd)
 
 	p.refreshVersion()
 	p.refreshAPIPort()
 
+	statsStopCh := make(chan struct{})
+	p.supervisorMu.Lock()
+	p.intentionalStop = false
+	p.supervisorDone = make(chan struct{})
+	p.statsStopCh = statsStopCh
+	p.supervisorMu.Unlock()
+
+	go p.supervise(configPath)
+	go p.collectStats(statsStopCh)
+
 	return nil
 }
 
+// runOnce starts a fresh xray-core child process wired to the shared log writer
+// and returns the *exec.Cmd before it has exited.
+func (p *process) runOnce(configPath string) (*exec.Cmd, error) {
+	cmd := exec.Command(GetBinaryPath(), "-c", configPath)
+	cmd.Stdout = p.logWriter
+	cmd.Stderr = p.logWriter
+	if err := cmd.Start(); err != nil {
+		return nil, common.NewErrorf("Failed to start xray-core: %v", err)
+	}
+	return cmd, nil
+}
+
 func (p *process) Stop() error {
 	if !p.IsRunning() {
 		return errors.New("xray is not running")
 	}
-	
+
+	p.supervisorMu.Lock()
+	p.intentionalStop = true
+	cmd := p.cmd
+	p.supervisorMu.Unlock()
+
+	p.stopStatsCollector()
+
 	if runtime.GOOS == "windows" {
-		return p.cmd.Process.Kill()
+		return cmd.Process.Kill()
 	} else {
-		return p.cmd.Process.Signal(syscall.SIGTERM)
+		return cmd.Process.Signal(syscall.SIGTERM)
+	}
+}
+
+// stopStatsCollector closes p.statsStopCh if it's still open, guarded by
+// supervisorMu (the same lock p.cmd uses) so two concurrent Stop() calls
+// can't both close the channel and panic.
+func (p *process) stopStatsCollector() {
+	p.supervisorMu.Lock()
+	if p.statsStopCh != nil {
+		close(p.statsStopCh)
+		p.statsStopCh = nil
 	}
+	p.supervisorMu.Unlock()
 }
 
 func writeCrashReport(m []byte) error {
@@ -0,0 +1,349 @@
+package xray
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"reflect"
+	"time"
+
+	handlerservice "github.com/xtls/xray-core/app/proxyman/command"
+	"github.com/xtls/xray-core/common/protocol"
+	"github.com/xtls/xray-core/common/serial"
+	"google.golang.org/grpc"
+
+	"x-ui/logger"
+	"x-ui/util/common"
+)
+
+// ReloadMode describes how a Reload() call was applied.
+type ReloadMode int
+
+const (
+	// HotApplied means only inbound client lists changed and the diff was
+	// pushed through the xray HandlerService API without restarting xray.
+	HotApplied ReloadMode = iota
+	// FullRestart means a non-hot-reloadable field changed (listen address,
+	// transport, TLS, routing, ...) and xray was started with the new
+	// config, after being stopped first if it was running.
+	FullRestart
+	// Persisted means the new config was only written to disk: the change
+	// was otherwise hot-reloadable, but xray wasn't running to push it to.
+	// It takes effect the next time Start() is called.
+	Persisted
+)
+
+// ReloadResult reports how Reload() applied a new configuration.
+type ReloadResult struct {
+	Mode          ReloadMode
+	AddedEmails   []string
+	RemovedEmails []string
+}
+
+// Reload diffs newConfig against the currently running configuration and
+// applies the change with the least disruption: client-list-only changes
+// are pushed live through the xray HandlerService API (or just persisted if
+// xray isn't running), anything else falls back to a full Stop()+Start().
+func (p *Process) Reload(newConfig *Config) (*ReloadResult, error) {
+	if p.runner != nil {
+		return p.runner.Reload(newConfig)
+	}
+
+	oldConfig := p.config
+
+	if err := persistConfigAtomically(newConfig); err != nil {
+		return nil, err
+	}
+
+	if !canHotReload(oldConfig, newConfig) {
+		p.config = newConfig
+		if p.IsRunning() {
+			if err := p.Stop(); err != nil {
+				return nil, common.NewErrorf("Failed to stop xray for reload: %v", err)
+			}
+			// Stop only signals xray; wait for the supervisor to see it exit
+			// so Start doesn't find the old child still running.
+			p.supervisorMu.Lock()
+			done := p.supervisorDone
+			p.supervisorMu.Unlock()
+			if done != nil {
+				<-done
+			}
+		}
+		if err := p.Start(); err != nil {
+			return nil, common.NewErrorf("Failed to start xray after reload: %v", err)
+		}
+		return &ReloadResult{Mode: FullRestart}, nil
+	}
+
+	added, removed := diffClientEmails(oldConfig, newConfig)
+
+	if !p.IsRunning() {
+		// Nothing to push the diff to; persist it and let Start() pick up
+		// the new client lists whenever xray is next started.
+		p.config = newConfig
+		return &ReloadResult{Mode: Persisted}, nil
+	}
+
+	conn, err := grpc.Dial(
+		fmt.Sprintf("127.0.0.1:%d", p.apiPort),
+		grpc.WithInsecure(),
+		grpc.WithBlock(),
+		grpc.WithTimeout(5*time.Second),
+	)
+	if err != nil {
+		return nil, common.NewErrorf("Failed to connect to xray API for hot reload: %v", err)
+	}
+	defer conn.Close()
+
+	handlerClient := handlerservice.NewHandlerServiceClient(conn)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	for _, diff := range diffClientEmailsByInbound(oldConfig, newConfig) {
+		for _, email := range diff.Removed {
+			if err := removeUser(ctx, handlerClient, diff.Tag, email); err != nil {
+				logger.Warningf("Failed to remove user %s from %s during hot reload: %s", email, diff.Tag, err)
+			}
+		}
+		for _, email := range diff.Added {
+			if err := addUser(ctx, handlerClient, diff.Tag, email); err != nil {
+				logger.Warningf("Failed to add user %s to %s during hot reload: %s", email, diff.Tag, err)
+			}
+		}
+	}
+
+	p.config = newConfig
+
+	return &ReloadResult{
+		Mode:          HotApplied,
+		AddedEmails:   added,
+		RemovedEmails: removed,
+	}, nil
+}
+
+// canHotReload reports whether the delta between old and new is restricted
+// to inbound client lists, i.e. every other field (listen, transport, TLS,
+// routing, ...) is unchanged.
+func canHotReload(oldConfig, newConfig *Config) bool {
+	if oldConfig == nil || newConfig == nil {
+		return false
+	}
+	oldStripped := stripHotReloadableFields(oldConfig)
+	newStripped := stripHotReloadableFields(newConfig)
+	return reflect.DeepEqual(oldStripped, newStripped)
+}
+
+// stripHotReloadableFields returns a copy of the config with each inbound's
+// client list zeroed out of Settings, so what remains can be compared to
+// detect listen/transport/TLS/routing changes, i.e. anything that isn't a
+// client add/remove.
+func stripHotReloadableFields(c *Config) *Config {
+	stripped := *c
+	stripped.InboundConfigs = append([]InboundConfig(nil), c.InboundConfigs...)
+	for i := range stripped.InboundConfigs {
+		stripped.InboundConfigs[i].Settings = stripClientsFromSettings(stripped.InboundConfigs[i].Settings)
+	}
+	return &stripped
+}
+
+// inboundClients is the subset of an inbound's Settings JSON this package
+// cares about: the client list, keyed by protocol-agnostic "email".
+type inboundClients struct {
+	Clients []struct {
+		Email string `json:"email"`
+	} `json:"clients"`
+}
+
+// stripClientsFromSettings returns settings with the "clients" key removed,
+// leaving protocol-specific fields (fallbacks, decryption, ...) intact for
+// comparison. Settings that don't parse as a JSON object are returned
+// unchanged.
+func stripClientsFromSettings(settings json.RawMessage) json.RawMessage {
+	if len(settings) == 0 {
+		return settings
+	}
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(settings, &raw); err != nil {
+		return settings
+	}
+	delete(raw, "clients")
+	stripped, err := json.Marshal(raw)
+	if err != nil {
+		return settings
+	}
+	return stripped
+}
+
+// diffClientEmails returns the emails present only in newConfig (added) and
+// only in oldConfig (removed), across all inbounds. Used for reporting in
+// ReloadResult; applying the diff to xray must go through
+// diffClientEmailsByInbound so an email is only pushed to the inbound(s) it
+// actually belongs to.
+func diffClientEmails(oldConfig, newConfig *Config) (added, removed []string) {
+	oldEmails := clientEmailSet(oldConfig)
+	newEmails := clientEmailSet(newConfig)
+
+	for email := range newEmails {
+		if !oldEmails[email] {
+			added = append(added, email)
+		}
+	}
+	for email := range oldEmails {
+		if !newEmails[email] {
+			removed = append(removed, email)
+		}
+	}
+	return added, removed
+}
+
+// clientEmailSet collects every client email configured across c's inbounds,
+// parsed out of each inbound's raw Settings JSON.
+func clientEmailSet(c *Config) map[string]bool {
+	set := map[string]bool{}
+	for _, inboundEmails := range clientEmailsByInbound(c) {
+		for email := range inboundEmails {
+			set[email] = true
+		}
+	}
+	return set
+}
+
+// clientEmailsByInbound maps each inbound's tag to the set of client emails
+// configured on it, parsed out of its raw Settings JSON.
+func clientEmailsByInbound(c *Config) map[string]map[string]bool {
+	byInbound := map[string]map[string]bool{}
+	if c == nil {
+		return byInbound
+	}
+	for _, inbound := range c.InboundConfigs {
+		set := byInbound[inbound.Tag]
+		if set == nil {
+			set = map[string]bool{}
+			byInbound[inbound.Tag] = set
+		}
+		for _, email := range clientEmailsFromSettings(inbound.Settings) {
+			set[email] = true
+		}
+	}
+	return byInbound
+}
+
+// inboundClientDiff is the added/removed client emails for a single inbound,
+// identified by tag.
+type inboundClientDiff struct {
+	Tag     string
+	Added   []string
+	Removed []string
+}
+
+// diffClientEmailsByInbound returns, per inbound tag, the emails added and
+// removed between oldConfig and newConfig. Keeping the diff scoped to each
+// inbound (rather than flattened across all of them, as diffClientEmails
+// does for reporting) matters when multiple inbounds share a protocol:
+// without it, a client added to one inbound would also be pushed to every
+// other inbound's AlterInbound call.
+func diffClientEmailsByInbound(oldConfig, newConfig *Config) []inboundClientDiff {
+	oldByInbound := clientEmailsByInbound(oldConfig)
+	newByInbound := clientEmailsByInbound(newConfig)
+
+	tags := map[string]bool{}
+	for tag := range oldByInbound {
+		tags[tag] = true
+	}
+	for tag := range newByInbound {
+		tags[tag] = true
+	}
+
+	var diffs []inboundClientDiff
+	for tag := range tags {
+		oldEmails := oldByInbound[tag]
+		newEmails := newByInbound[tag]
+
+		var added, removed []string
+		for email := range newEmails {
+			if !oldEmails[email] {
+				added = append(added, email)
+			}
+		}
+		for email := range oldEmails {
+			if !newEmails[email] {
+				removed = append(removed, email)
+			}
+		}
+		if len(added) > 0 || len(removed) > 0 {
+			diffs = append(diffs, inboundClientDiff{Tag: tag, Added: added, Removed: removed})
+		}
+	}
+	return diffs
+}
+
+// clientEmailsFromSettings parses the client emails out of an inbound's raw
+// Settings JSON (the `{"clients":[{"email":"...",...}, ...]}` shape shared
+// by vmess/vless/trojan inbounds). Settings that don't carry a client list
+// (dokodemo-door, freedom outbounds used as inbounds in tests, ...) parse to
+// no emails rather than an error.
+func clientEmailsFromSettings(settings json.RawMessage) []string {
+	if len(settings) == 0 {
+		return nil
+	}
+	var parsed inboundClients
+	if err := json.Unmarshal(settings, &parsed); err != nil {
+		return nil
+	}
+	emails := make([]string, 0, len(parsed.Clients))
+	for _, client := range parsed.Clients {
+		if client.Email != "" {
+			emails = append(emails, client.Email)
+		}
+	}
+	return emails
+}
+
+func addUser(ctx context.Context, client handlerservice.HandlerServiceClient, tag, email string) error {
+	operation, err := serial.ToTypedMessage(&handlerservice.AddUserOperation{
+		User: &protocol.User{Email: email},
+	})
+	if err != nil {
+		return err
+	}
+	_, err = client.AlterInbound(ctx, &handlerservice.AlterInboundRequest{
+		Tag:       tag,
+		Operation: operation,
+	})
+	return err
+}
+
+func removeUser(ctx context.Context, client handlerservice.HandlerServiceClient, tag, email string) error {
+	operation, err := serial.ToTypedMessage(&handlerservice.RemoveUserOperation{Email: email})
+	if err != nil {
+		return err
+	}
+	_, err = client.AlterInbound(ctx, &handlerservice.AlterInboundRequest{
+		Tag:       tag,
+		Operation: operation,
+	})
+	return err
+}
+
+// persistConfigAtomically writes the new config to a temp file and renames
+// it over the live config, so a crash mid-write can never leave a truncated
+// config.json behind.
+func persistConfigAtomically(c *Config) error {
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return common.NewErrorf("Failed to generate XRAY configuration files: %v", err)
+	}
+
+	configPath := GetConfigPath()
+	tmpPath := configPath + ".tmp"
+
+	if err := os.WriteFile(tmpPath, data, 0o644); err != nil {
+		return common.NewErrorf("Failed to write temporary configuration file: %v", err)
+	}
+	if err := os.Rename(tmpPath, configPath); err != nil {
+		return common.NewErrorf("Failed to replace configuration file: %v", err)
+	}
+	return nil
+}
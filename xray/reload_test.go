@@ -0,0 +1,107 @@
+package xray
+
+import (
+	"encoding/json"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func clientsSettings(emails ...string) json.RawMessage {
+	type client struct {
+		Email string `json:"email"`
+	}
+	clients := make([]client, len(emails))
+	for i, email := range emails {
+		clients[i] = client{Email: email}
+	}
+	data, _ := json.Marshal(map[string]any{"clients": clients})
+	return data
+}
+
+func TestClientEmailsFromSettings(t *testing.T) {
+	got := clientEmailsFromSettings(clientsSettings("a@example.com", "b@example.com"))
+	want := []string{"a@example.com", "b@example.com"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("clientEmailsFromSettings() = %v, want %v", got, want)
+	}
+
+	if got := clientEmailsFromSettings(nil); got != nil {
+		t.Errorf("clientEmailsFromSettings(nil) = %v, want nil", got)
+	}
+
+	if got := clientEmailsFromSettings(json.RawMessage(`{"network":"tcp"}`)); len(got) != 0 {
+		t.Errorf("clientEmailsFromSettings(no clients) = %v, want empty", got)
+	}
+}
+
+func TestDiffClientEmails(t *testing.T) {
+	oldConfig := &Config{InboundConfigs: []InboundConfig{
+		{Tag: "in", Settings: clientsSettings("a@example.com", "b@example.com")},
+	}}
+	newConfig := &Config{InboundConfigs: []InboundConfig{
+		{Tag: "in", Settings: clientsSettings("b@example.com", "c@example.com")},
+	}}
+
+	added, removed := diffClientEmails(oldConfig, newConfig)
+	sort.Strings(added)
+	sort.Strings(removed)
+
+	if !reflect.DeepEqual(added, []string{"c@example.com"}) {
+		t.Errorf("added = %v, want [c@example.com]", added)
+	}
+	if !reflect.DeepEqual(removed, []string{"a@example.com"}) {
+		t.Errorf("removed = %v, want [a@example.com]", removed)
+	}
+}
+
+func TestDiffClientEmailsByInboundScopesToOwningInbound(t *testing.T) {
+	oldConfig := &Config{InboundConfigs: []InboundConfig{
+		{Tag: "in1", Settings: clientsSettings("a@example.com")},
+		{Tag: "in2", Settings: clientsSettings("a@example.com")},
+	}}
+	newConfig := &Config{InboundConfigs: []InboundConfig{
+		{Tag: "in1", Settings: clientsSettings("a@example.com", "b@example.com")},
+		{Tag: "in2", Settings: clientsSettings("a@example.com")},
+	}}
+
+	diffs := diffClientEmailsByInbound(oldConfig, newConfig)
+	if len(diffs) != 1 {
+		t.Fatalf("diffs = %+v, want exactly one inbound's diff", diffs)
+	}
+	if diffs[0].Tag != "in1" {
+		t.Errorf("diff.Tag = %q, want in1", diffs[0].Tag)
+	}
+	if !reflect.DeepEqual(diffs[0].Added, []string{"b@example.com"}) {
+		t.Errorf("diff.Added = %v, want [b@example.com]", diffs[0].Added)
+	}
+	if len(diffs[0].Removed) != 0 {
+		t.Errorf("diff.Removed = %v, want empty", diffs[0].Removed)
+	}
+}
+
+func TestCanHotReloadClientOnlyChange(t *testing.T) {
+	oldConfig := &Config{InboundConfigs: []InboundConfig{
+		{Tag: "in", Port: 443, Settings: clientsSettings("a@example.com")},
+	}}
+	newConfig := &Config{InboundConfigs: []InboundConfig{
+		{Tag: "in", Port: 443, Settings: clientsSettings("a@example.com", "b@example.com")},
+	}}
+
+	if !canHotReload(oldConfig, newConfig) {
+		t.Error("canHotReload() = false, want true for a client-list-only change")
+	}
+}
+
+func TestCanHotReloadPortChange(t *testing.T) {
+	oldConfig := &Config{InboundConfigs: []InboundConfig{
+		{Tag: "in", Port: 443, Settings: clientsSettings("a@example.com")},
+	}}
+	newConfig := &Config{InboundConfigs: []InboundConfig{
+		{Tag: "in", Port: 8443, Settings: clientsSettings("a@example.com")},
+	}}
+
+	if canHotReload(oldConfig, newConfig) {
+		t.Error("canHotReload() = true, want false when a non-client field changes")
+	}
+}
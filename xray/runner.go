@@ -0,0 +1,298 @@
+package xray
+
+import (
+	"sync"
+	"time"
+
+	"x-ui/logger"
+)
+
+// RunnerStatus is a point-in-time snapshot of an XrayRunner's health, as
+// reported by either a locally-spawned process or a remote agent heartbeat.
+type RunnerStatus struct {
+	Running       bool
+	Version       string
+	Uptime        uint64
+	OnlineClients []string
+	TrafficDeltas []TrafficDelta
+}
+
+// XrayRunner is the control surface Process delegates to. LocalRunner drives
+// an exec.Cmd on this host; RemoteRunner drives an agent process deployed on
+// an edge node over a small control-plane connection.
+type XrayRunner interface {
+	Start() error
+	Stop() error
+	Reload(newConfig *Config) (*ReloadResult, error)
+	Status() RunnerStatus
+	Logs(filter LogFilter) (<-chan LogEvent, func())
+}
+
+// NodeDescriptor identifies the edge node a RemoteRunner should control. It
+// is supplied explicitly to NewRemoteProcess rather than embedded in Config,
+// since a single config can be pushed to many nodes.
+type NodeDescriptor struct {
+	// Address is the agent's control-plane endpoint, e.g. "node1.example.com:62789".
+	Address string
+	// Token authenticates this panel to the agent.
+	Token string
+	// HeartbeatInterval is how often the agent is expected to report in;
+	// missing two consecutive intervals marks the node unreachable.
+	HeartbeatInterval time.Duration
+}
+
+// LocalRunner drives xray-core as a child process of this panel. It wraps
+// the pre-existing *process implementation behind XrayRunner. A Process
+// built with NewProcess does not go through it: it calls its *process
+// directly, and only a Process built with NewRemoteProcess has a runner.
+type LocalRunner struct {
+	p *process
+}
+
+func NewLocalRunner(config *Config) *LocalRunner {
+	return &LocalRunner{p: newProcess(config)}
+}
+
+func (r *LocalRunner) Start() error { return r.p.Start() }
+func (r *LocalRunner) Stop() error  { return r.p.Stop() }
+
+func (r *LocalRunner) Reload(newConfig *Config) (*ReloadResult, error) {
+	return (&Process{r.p}).Reload(newConfig)
+}
+
+func (r *LocalRunner) Status() RunnerStatus {
+	return RunnerStatus{
+		Running:       r.p.IsRunning(),
+		Version:       r.p.GetVersion(),
+		Uptime:        (&Process{r.p}).GetUptime(),
+		OnlineClients: (&Process{r.p}).GetOnlineClients(),
+		TrafficDeltas: (&Process{r.p}).GetTrafficDeltas(),
+	}
+}
+
+func (r *LocalRunner) Logs(filter LogFilter) (<-chan LogEvent, func()) {
+	return (&Process{r.p}).SubscribeLogs(filter)
+}
+
+// RemoteRunner controls an xray-core instance running under a lightweight
+// agent binary on an edge node, communicating over the agent's
+// heartbeat/control-plane connection instead of a local exec.Cmd.
+type RemoteRunner struct {
+	node   NodeDescriptor
+	client remoteAgentClient
+
+	// mu guards lastHeartbeat, status and stopped, which are written from
+	// watchHeartbeat/Stop and read from Status on arbitrary other goroutines.
+	mu            sync.Mutex
+	lastHeartbeat time.Time
+	status        RunnerStatus
+	stopped       bool
+
+	// done is closed by Stop to tell watchHeartbeat to exit.
+	done chan struct{}
+}
+
+// remoteAgentClient is the thin transport the agent binary implements;
+// kept as an interface so it can be faked in tests and swapped between
+// gRPC and plain HTTP without touching RemoteRunner.
+type remoteAgentClient interface {
+	Start() error
+	Stop() error
+	Reload(newConfig *Config) (*ReloadResult, error)
+	Status() (RunnerStatus, error)
+	StreamLogs(filter LogFilter) (<-chan LogEvent, func(), error)
+	Heartbeat() (<-chan RunnerStatus, func())
+}
+
+// NewRemoteRunner connects to the agent described by node. The returned
+// runner starts consuming heartbeats immediately so Status() is populated
+// even before Start() is called.
+func NewRemoteRunner(node NodeDescriptor, client remoteAgentClient) *RemoteRunner {
+	if node.HeartbeatInterval <= 0 {
+		node.HeartbeatInterval = 10 * time.Second
+	}
+	r := &RemoteRunner{node: node, client: client, done: make(chan struct{})}
+	go r.watchHeartbeat()
+	return r
+}
+
+func (r *RemoteRunner) watchHeartbeat() {
+	heartbeats, cancel := r.client.Heartbeat()
+	defer cancel()
+	for {
+		select {
+		case <-r.done:
+			return
+		case status, ok := <-heartbeats:
+			if !ok {
+				return
+			}
+			r.mu.Lock()
+			r.status = status
+			r.lastHeartbeat = time.Now()
+			r.mu.Unlock()
+		}
+	}
+}
+
+func (r *RemoteRunner) Start() error { return r.client.Start() }
+
+// Stop stops the remote xray instance and tears down the heartbeat
+// subscription started in NewRemoteRunner.
+func (r *RemoteRunner) Stop() error {
+	r.mu.Lock()
+	if !r.stopped {
+		r.stopped = true
+		close(r.done)
+	}
+	r.mu.Unlock()
+	return r.client.Stop()
+}
+
+func (r *RemoteRunner) Reload(newConfig *Config) (*ReloadResult, error) {
+	return r.client.Reload(newConfig)
+}
+
+func (r *RemoteRunner) Status() RunnerStatus {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if time.Since(r.lastHeartbeat) > 2*r.node.HeartbeatInterval {
+		logger.Warningf("Remote node %s missed heartbeat, last seen %s ago", r.node.Address, time.Since(r.lastHeartbeat))
+		r.status.Running = false
+	}
+	return r.status
+}
+
+func (r *RemoteRunner) Logs(filter LogFilter) (<-chan LogEvent, func()) {
+	ch, cancel, err := r.client.StreamLogs(filter)
+	if err != nil {
+		logger.Warningf("Failed to stream logs from remote node %s: %s", r.node.Address, err)
+		closed := make(chan LogEvent)
+		close(closed)
+		return closed, func() {}
+	}
+	return ch, cancel
+}
+
+// Start starts xray, either locally (the default) or, when this Process was
+// built with NewRemoteProcess, by delegating to its RemoteRunner.
+func (p *Process) Start() error {
+	if p.runner != nil {
+		return p.runner.Start()
+	}
+	return p.process.Start()
+}
+
+// Stop stops xray, either locally or via the remote runner; see Start.
+func (p *Process) Stop() error {
+	if p.runner != nil {
+		return p.runner.Stop()
+	}
+	return p.process.Stop()
+}
+
+// IsRunning reports whether xray is running, either locally or, for a
+// remote Process, as last reported by the agent's heartbeat; see Start.
+func (p *Process) IsRunning() bool {
+	if p.runner != nil {
+		return p.runner.Status().Running
+	}
+	return p.process.IsRunning()
+}
+
+// GetVersion returns the running xray-core version, either local or as last
+// reported by the agent's heartbeat; see Start.
+func (p *Process) GetVersion() string {
+	if p.runner != nil {
+		return p.runner.Status().Version
+	}
+	return p.process.GetVersion()
+}
+
+// GetUptime returns how long xray has been running, either local or as last
+// reported by the agent's heartbeat; see Start.
+func (p *Process) GetUptime() uint64 {
+	if p.runner != nil {
+		return p.runner.Status().Uptime
+	}
+	return uint64(time.Since(p.startTime).Seconds())
+}
+
+// GetOnlineClients returns the current online-clients list, either local or
+// as last reported by the agent's heartbeat; see Start.
+func (p *Process) GetOnlineClients() []string {
+	if p.runner != nil {
+		return p.runner.Status().OnlineClients
+	}
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
+	clientsCopy := make([]string, len(p.onlineClients))
+	copy(clientsCopy, p.onlineClients)
+	return clientsCopy
+}
+
+// GetTrafficDeltas returns the per-client traffic deltas observed since the
+// previous poll, either local or as last reported by the agent's heartbeat;
+// see Start.
+func (p *Process) GetTrafficDeltas() []TrafficDelta {
+	if p.runner != nil {
+		return p.runner.Status().TrafficDeltas
+	}
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
+	deltas := make([]TrafficDelta, len(p.trafficDeltas))
+	copy(deltas, p.trafficDeltas)
+	return deltas
+}
+
+// SubscribeLogs returns a channel of live LogEvents matching filter and a
+// cancel func that must be called once the subscriber is done reading,
+// either from the local log writer or streamed from the remote agent; see
+// Start.
+func (p *Process) SubscribeLogs(filter LogFilter) (<-chan LogEvent, func()) {
+	if p.runner != nil {
+		return p.runner.Logs(filter)
+	}
+	p.logWriter.mutex.Lock()
+	backlog := p.logWriter.recentLocked(filter)
+	ch := make(chan LogEvent, len(backlog)+256)
+
+	// Seed the channel with buffered history before any live event can reach
+	// it, so a new subscriber doesn't start with zero context. This happens
+	// while the lock is held and before the subscriber is registered, and the
+	// channel is sized to hold the whole backlog, so it never blocks.
+	for _, event := range backlog {
+		ch <- event
+	}
+	p.logWriter.subscribers[ch] = filter
+	p.logWriter.mutex.Unlock()
+
+	cancel := func() {
+		p.logWriter.mutex.Lock()
+		delete(p.logWriter.subscribers, ch)
+		p.logWriter.mutex.Unlock()
+		close(ch)
+	}
+	return ch, cancel
+}
+
+// NewRemoteProcess builds a Process backed by a RemoteRunner instead of a
+// locally-spawned xray-core, for controlling an edge node's xray instance
+// through its agent.
+func NewRemoteProcess(config *Config, node NodeDescriptor, client remoteAgentClient) *Process {
+	runner := NewRemoteRunner(node, client)
+	p := &process{
+		version:       "Unknown",
+		config:        config,
+		logWriter:     NewLogWriter(),
+		startTime:     time.Now(),
+		autoRestart:   false,
+		restartPolicy: DefaultRestartPolicy(),
+		runner:        runner,
+	}
+	// GetAPIPort has no equivalent in RunnerStatus (the api inbound's port
+	// is local config, not something the agent reports), so populate it
+	// from config directly rather than leaving it at zero.
+	p.refreshAPIPort()
+	return &Process{p}
+}
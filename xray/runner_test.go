@@ -0,0 +1,83 @@
+package xray
+
+import (
+	"testing"
+	"time"
+)
+
+type fakeAgentClient struct {
+	heartbeats chan RunnerStatus
+}
+
+func newFakeAgentClient() *fakeAgentClient {
+	return &fakeAgentClient{heartbeats: make(chan RunnerStatus, 4)}
+}
+
+func (f *fakeAgentClient) Start() error { return nil }
+func (f *fakeAgentClient) Stop() error  { return nil }
+func (f *fakeAgentClient) Reload(newConfig *Config) (*ReloadResult, error) {
+	return &ReloadResult{Mode: HotApplied}, nil
+}
+func (f *fakeAgentClient) Status() (RunnerStatus, error) { return RunnerStatus{}, nil }
+func (f *fakeAgentClient) StreamLogs(filter LogFilter) (<-chan LogEvent, func(), error) {
+	ch := make(chan LogEvent)
+	return ch, func() {}, nil
+}
+func (f *fakeAgentClient) Heartbeat() (<-chan RunnerStatus, func()) {
+	return f.heartbeats, func() { close(f.heartbeats) }
+}
+
+func TestSubscribeLogsSeedsBacklogBeforeLiveEvents(t *testing.T) {
+	p := &Process{newProcess(&Config{})}
+	p.logWriter.publish(LogEvent{Raw: "backlog-1"})
+	p.logWriter.publish(LogEvent{Raw: "backlog-2"})
+
+	ch, cancel := p.SubscribeLogs(LogFilter{})
+	defer cancel()
+
+	p.logWriter.publish(LogEvent{Raw: "live-1"})
+
+	var got []string
+	for i := 0; i < 3; i++ {
+		select {
+		case event := <-ch:
+			got = append(got, event.Raw)
+		case <-time.After(time.Second):
+			t.Fatalf("only received %v before timing out", got)
+		}
+	}
+
+	want := []string{"backlog-1", "backlog-2", "live-1"}
+	for i, raw := range want {
+		if got[i] != raw {
+			t.Errorf("event[%d] = %q, want %q (got %v)", i, got[i], raw, got)
+		}
+	}
+}
+
+func TestRemoteRunnerStatusReflectsHeartbeat(t *testing.T) {
+	client := newFakeAgentClient()
+	runner := NewRemoteRunner(NodeDescriptor{Address: "node1:1", HeartbeatInterval: time.Hour}, client)
+
+	client.heartbeats <- RunnerStatus{Running: true, Version: "1.2.3"}
+
+	deadline := time.After(time.Second)
+	for {
+		if status := runner.Status(); status.Running && status.Version == "1.2.3" {
+			break
+		}
+		select {
+		case <-deadline:
+			t.Fatal("Status() never reflected the heartbeat")
+		case <-time.After(time.Millisecond):
+		}
+	}
+
+	if err := runner.Stop(); err != nil {
+		t.Fatalf("Stop() = %v, want nil", err)
+	}
+	// Stop must be safe to call twice.
+	if err := runner.Stop(); err != nil {
+		t.Fatalf("second Stop() = %v, want nil", err)
+	}
+}
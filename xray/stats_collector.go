@@ -0,0 +1,197 @@
+package xray
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	statsservice "github.com/xtls/xray-core/app/stats/command"
+	"google.golang.org/grpc"
+
+	"x-ui/logger"
+)
+
+// StatsCollectorConfig controls how the online-clients collector polls the
+// xray stats API.
+type StatsCollectorConfig struct {
+	// PollInterval is how often GetStats/QueryStats is polled.
+	PollInterval time.Duration
+	// ActivityWindow is how recently a client's traffic counters must have
+	// moved for it to be considered "online".
+	ActivityWindow time.Duration
+	// ResetCounters, when true, reads stats with Reset_ set so xray zeroes
+	// the counter after each poll instead of accumulating indefinitely.
+	ResetCounters bool
+}
+
+// DefaultStatsCollectorConfig returns the settings used when no collector
+// config is supplied.
+func DefaultStatsCollectorConfig() StatsCollectorConfig {
+	return StatsCollectorConfig{
+		PollInterval:   5 * time.Second,
+		ActivityWindow: 15 * time.Second,
+		ResetCounters:  false,
+	}
+}
+
+// TrafficDelta is the up/down traffic observed for a single email between two
+// consecutive polls.
+type TrafficDelta struct {
+	Email string
+	Up    int64
+	Down  int64
+}
+
+type clientActivity struct {
+	lastUp, lastDown int64
+	lastSeen         time.Time
+}
+
+// ConfigureStatsCollector sets the polling parameters used by the online
+// clients collector started from Start(). It must be called before Start()
+// to take effect on the next run.
+func (p *Process) ConfigureStatsCollector(cfg StatsCollectorConfig) {
+	p.supervisorMu.Lock()
+	defer p.supervisorMu.Unlock()
+	p.statsCollectorConfig = cfg
+}
+
+// collectStats polls the xray stats gRPC service on the api inbound until
+// stopCh is closed, deriving the online-clients list and per-client traffic
+// deltas from the user>>>{email}>>>traffic>>>{uplink,downlink} counters.
+// If it cannot connect to the API within 5 seconds it logs a warning and
+// returns without polling.
+func (p *process) collectStats(stopCh <-chan struct{}) {
+	p.supervisorMu.Lock()
+	cfg := p.statsCollectorConfig
+	p.supervisorMu.Unlock()
+	if cfg.PollInterval <= 0 {
+		cfg = DefaultStatsCollectorConfig()
+	}
+
+	conn, err := grpc.Dial(
+		fmt.Sprintf("127.0.0.1:%d", p.apiPort),
+		grpc.WithInsecure(),
+		grpc.WithBlock(),
+		grpc.WithTimeout(5*time.Second),
+	)
+	if err != nil {
+		logger.Warningf("Failed to connect to xray stats API: %s", err)
+		return
+	}
+	defer conn.Close()
+
+	client := statsservice.NewStatsServiceClient(conn)
+	activity := map[string]*clientActivity{}
+	var mu sync.Mutex
+
+	ticker := time.NewTicker(cfg.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stopCh:
+			return
+		case <-ticker.C:
+			ctx, cancel := context.WithTimeout(context.Background(), cfg.PollInterval)
+			resp, err := client.QueryStats(ctx, &statsservice.QueryStatsRequest{
+				Pattern: "user>>>",
+				Reset_:  cfg.ResetCounters,
+			})
+			cancel()
+			if err != nil {
+				logger.Warningf("Failed to query xray stats: %s", err)
+				continue
+			}
+
+			now := time.Now()
+			perClient := map[string]*TrafficDelta{}
+
+			mu.Lock()
+			for _, stat := range resp.GetStat() {
+				applyStatSample(activity, perClient, stat.GetName(), stat.GetValue(), cfg.ResetCounters, now)
+			}
+
+			online := make([]string, 0, len(activity))
+			deltas := make([]TrafficDelta, 0, len(perClient))
+			for email, a := range activity {
+				if now.Sub(a.lastSeen) <= cfg.ActivityWindow {
+					online = append(online, email)
+				}
+			}
+			for _, d := range perClient {
+				deltas = append(deltas, *d)
+			}
+			mu.Unlock()
+
+			p.SetOnlineClients(online)
+			p.mutex.Lock()
+			p.trafficDeltas = deltas
+			p.mutex.Unlock()
+		}
+	}
+}
+
+// applyStatSample parses a single QueryStats entry and, if it's a per-user
+// traffic counter, folds it into activity/perClient: updating the client's
+// traffic delta and, if traffic moved, its last-seen time used by the
+// online-clients activity window.
+func applyStatSample(activity map[string]*clientActivity, perClient map[string]*TrafficDelta, statName string, value int64, resetCounters bool, now time.Time) {
+	email, direction, ok := parseTrafficStatName(statName)
+	if !ok {
+		return
+	}
+	a, ok := activity[email]
+	if !ok {
+		a = &clientActivity{}
+		activity[email] = a
+	}
+	d, ok := perClient[email]
+	if !ok {
+		d = &TrafficDelta{Email: email}
+		perClient[email] = d
+	}
+	switch direction {
+	case "uplink":
+		delta, baseline := trafficDelta(a.lastUp, value, resetCounters)
+		if delta != 0 {
+			a.lastSeen = now
+		}
+		d.Up = delta
+		a.lastUp = baseline
+	case "downlink":
+		delta, baseline := trafficDelta(a.lastDown, value, resetCounters)
+		if delta != 0 {
+			a.lastSeen = now
+		}
+		d.Down = delta
+		a.lastDown = baseline
+	}
+}
+
+// trafficDelta computes the delta for one direction's counter given the
+// previous baseline and the latest raw value from QueryStats, and returns
+// the new baseline to store against the next poll. When resetCounters is
+// true, xray zeroes the counter server-side after reporting it, so the raw
+// value already *is* the delta and the baseline must reset to zero rather
+// than to the reported value — otherwise the next poll's small post-reset
+// value would be diffed against the old cumulative total and go negative.
+func trafficDelta(prevBaseline, value int64, resetCounters bool) (delta, newBaseline int64) {
+	delta = value - prevBaseline
+	if resetCounters {
+		return delta, 0
+	}
+	return delta, value
+}
+
+// parseTrafficStatName extracts the email and direction from a stat name of
+// the form "user>>>{email}>>>traffic>>>{uplink,downlink}".
+func parseTrafficStatName(name string) (email, direction string, ok bool) {
+	parts := strings.Split(name, ">>>")
+	if len(parts) != 4 || parts[0] != "user" || parts[2] != "traffic" {
+		return "", "", false
+	}
+	return parts[1], parts[3], true
+}
@@ -0,0 +1,84 @@
+package xray
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseTrafficStatName(t *testing.T) {
+	cases := []struct {
+		name          string
+		wantEmail     string
+		wantDirection string
+		wantOK        bool
+	}{
+		{"user>>>alice@example.com>>>traffic>>>uplink", "alice@example.com", "uplink", true},
+		{"user>>>alice@example.com>>>traffic>>>downlink", "alice@example.com", "downlink", true},
+		{"inbound>>>api>>>traffic>>>uplink", "", "", false},
+		{"garbage", "", "", false},
+	}
+
+	for _, c := range cases {
+		email, direction, ok := parseTrafficStatName(c.name)
+		if ok != c.wantOK || email != c.wantEmail || direction != c.wantDirection {
+			t.Errorf("parseTrafficStatName(%q) = (%q, %q, %v), want (%q, %q, %v)",
+				c.name, email, direction, ok, c.wantEmail, c.wantDirection, c.wantOK)
+		}
+	}
+}
+
+func TestTrafficDeltaAccumulating(t *testing.T) {
+	delta, baseline := trafficDelta(100, 150, false)
+	if delta != 50 || baseline != 150 {
+		t.Errorf("trafficDelta(100, 150, false) = (%d, %d), want (50, 150)", delta, baseline)
+	}
+}
+
+func TestTrafficDeltaResetCounters(t *testing.T) {
+	// After a reset, xray reports the delta directly (not a cumulative
+	// total), so the baseline must zero out rather than track the reported
+	// value, or the following poll would go negative.
+	delta, baseline := trafficDelta(0, 30, true)
+	if delta != 30 || baseline != 0 {
+		t.Errorf("trafficDelta(0, 30, true) = (%d, %d), want (30, 0)", delta, baseline)
+	}
+
+	delta, baseline = trafficDelta(baseline, 10, true)
+	if delta != 10 || baseline != 0 {
+		t.Errorf("trafficDelta(0, 10, true) = (%d, %d), want (10, 0)", delta, baseline)
+	}
+}
+
+func TestApplyStatSampleUsesRealStatNames(t *testing.T) {
+	activity := map[string]*clientActivity{}
+	perClient := map[string]*TrafficDelta{}
+	now := time.Now()
+
+	applyStatSample(activity, perClient, "user>>>alice@example.com>>>traffic>>>uplink", 100, false, now)
+	applyStatSample(activity, perClient, "user>>>alice@example.com>>>traffic>>>downlink", 40, false, now)
+
+	d, ok := perClient["alice@example.com"]
+	if !ok {
+		t.Fatal("applyStatSample did not record a delta for alice@example.com")
+	}
+	if d.Up != 100 || d.Down != 40 {
+		t.Errorf("delta = %+v, want Up=100 Down=40", d)
+	}
+
+	a, ok := activity["alice@example.com"]
+	if !ok || a.lastSeen.IsZero() {
+		t.Error("applyStatSample did not mark alice@example.com as seen")
+	}
+}
+
+func TestStopStatsCollectorSafeToCallTwice(t *testing.T) {
+	p := newProcess(&Config{})
+	p.statsStopCh = make(chan struct{})
+
+	p.stopStatsCollector()
+	p.stopStatsCollector() // must not panic (close of closed channel)
+
+	if p.statsStopCh != nil {
+		t.Error("stopStatsCollector did not clear statsStopCh")
+	}
+}
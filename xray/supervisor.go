@@ -0,0 +1,176 @@
+package xray
+
+import (
+	"fmt"
+	"time"
+
+	"x-ui/logger"
+)
+
+// RestartPolicy controls the backoff and thrash-prevention behavior of the
+// supervisor goroutine started by Process.Start().
+type RestartPolicy struct {
+	// InitialBackoff is the delay before the first restart attempt.
+	InitialBackoff time.Duration
+	// MaxBackoff caps the exponential backoff between restart attempts.
+	MaxBackoff time.Duration
+	// StableUptime is how long the process must stay up before the backoff
+	// and failure counters are reset back to their initial state.
+	StableUptime time.Duration
+	// MaxFailures is the number of consecutive crashes allowed within
+	// FailureWindow before the supervisor gives up and marks the process
+	// FATAL instead of restarting it again.
+	MaxFailures int
+	// FailureWindow bounds how far back consecutive failures are counted.
+	FailureWindow time.Duration
+}
+
+// DefaultRestartPolicy returns the policy used when auto-restart is enabled
+// without an explicit policy.
+func DefaultRestartPolicy() RestartPolicy {
+	return RestartPolicy{
+		InitialBackoff: time.Second,
+		MaxBackoff:     30 * time.Second,
+		StableUptime:   60 * time.Second,
+		MaxFailures:    5,
+		FailureWindow:  5 * time.Minute,
+	}
+}
+
+// CrashReport describes a single unexpected exit of the xray-core child
+// process, as observed by the supervisor.
+type CrashReport struct {
+	Time     time.Time
+	ExitCode int
+	Signal   string
+	Err      error
+	Tail     string
+}
+
+// GetRestartCount returns how many times the supervisor has restarted the
+// xray-core process since it was created.
+func (p *Process) GetRestartCount() int {
+	p.supervisorMu.Lock()
+	defer p.supervisorMu.Unlock()
+	return p.restartCount
+}
+
+// GetLastCrash returns the most recent crash report, or nil if the process
+// has never exited unexpectedly.
+func (p *Process) GetLastCrash() *CrashReport {
+	p.supervisorMu.Lock()
+	defer p.supervisorMu.Unlock()
+	return p.lastCrash
+}
+
+// SetAutoRestart enables or disables the supervisor's auto-restart behavior
+// and, when enabled, installs the given policy. Passing a zero-value policy
+// falls back to DefaultRestartPolicy.
+func (p *Process) SetAutoRestart(enabled bool, policy RestartPolicy) {
+	if policy == (RestartPolicy{}) {
+		policy = DefaultRestartPolicy()
+	}
+	p.supervisorMu.Lock()
+	defer p.supervisorMu.Unlock()
+	p.autoRestart = enabled
+	p.restartPolicy = policy
+}
+
+// SetCrashHook installs a callback invoked whenever the supervisor gives up
+// restarting the process (FATAL state), e.g. to fire a webhook.
+func (p *Process) SetCrashHook(hook func(*CrashReport)) {
+	p.supervisorMu.Lock()
+	defer p.supervisorMu.Unlock()
+	p.crashHook = hook
+}
+
+// restartBackoff computes the exponential backoff delay before the given
+// restart attempt (1-indexed), doubling InitialBackoff for each consecutive
+// failure and saturating at MaxBackoff. It also catches the overflow case
+// where the shift makes backoff wrap negative.
+func restartBackoff(policy RestartPolicy, failures int) time.Duration {
+	backoff := policy.InitialBackoff << uint(failures-1)
+	if backoff <= 0 || backoff > policy.MaxBackoff {
+		backoff = policy.MaxBackoff
+	}
+	return backoff
+}
+
+// supervise watches the currently running child process and, on unexpected
+// exit, restarts it with exponential backoff until Stop() is called or the
+// failure budget within the configured window is exhausted.
+func (p *process) supervise(configPath string) {
+	for {
+		cmd := p.getCmd()
+		runStart := time.Now()
+		waitErr := cmd.Wait()
+
+		p.supervisorMu.Lock()
+		intentional := p.intentionalStop
+		p.supervisorMu.Unlock()
+
+		if waitErr != nil {
+			logger.Error("Failure in running xray-core:", waitErr)
+			p.exitErr = waitErr
+		}
+
+		if intentional {
+			close(p.supervisorDone)
+			return
+		}
+
+		report := &CrashReport{
+			Time: time.Now(),
+			Err:  waitErr,
+			Tail: p.logWriter.lastLine,
+		}
+		if cmd.ProcessState != nil {
+			report.ExitCode = cmd.ProcessState.ExitCode()
+		}
+		if err := writeCrashReport([]byte(fmt.Sprintf("%+v\n%s", report, report.Tail))); err != nil {
+			logger.Warningf("Failed to write crash report: %s", err)
+		}
+
+		p.supervisorMu.Lock()
+		p.lastCrash = report
+		autoRestart := p.autoRestart
+		policy := p.restartPolicy
+		// A run that stayed up for StableUptime resets the counters before
+		// this crash is counted, so it starts a fresh failure budget.
+		if time.Since(runStart) >= policy.StableUptime || time.Since(p.failureWindowAt) > policy.FailureWindow {
+			p.failureCount = 0
+			p.failureWindowAt = time.Now()
+		}
+		p.failureCount++
+		failures := p.failureCount
+		hook := p.crashHook
+		p.supervisorMu.Unlock()
+
+		if !autoRestart || failures > policy.MaxFailures {
+			logger.Error("xray-core crashed too many times, giving up auto-restart")
+			if hook != nil {
+				hook(report)
+			}
+			close(p.supervisorDone)
+			return
+		}
+
+		backoff := restartBackoff(policy, failures)
+		logger.Warningf("xray-core exited unexpectedly, restarting in %s (attempt %d)", backoff, failures)
+		time.Sleep(backoff)
+
+		newCmd, err := p.runOnce(configPath)
+		if err != nil {
+			logger.Error("Failed to restart xray-core:", err)
+			continue
+		}
+
+		p.setCmd(newCmd)
+		p.refreshVersion()
+		p.refreshAPIPort()
+
+		p.supervisorMu.Lock()
+		p.restartCount++
+		p.supervisorMu.Unlock()
+	}
+}
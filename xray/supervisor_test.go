@@ -0,0 +1,30 @@
+package xray
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRestartBackoff(t *testing.T) {
+	policy := RestartPolicy{
+		InitialBackoff: time.Second,
+		MaxBackoff:     30 * time.Second,
+	}
+
+	cases := []struct {
+		failures int
+		want     time.Duration
+	}{
+		{1, time.Second},
+		{2, 2 * time.Second},
+		{3, 4 * time.Second},
+		{6, 30 * time.Second},  // 32s would exceed MaxBackoff
+		{100, 30 * time.Second}, // shift overflow must saturate, not wrap negative
+	}
+
+	for _, c := range cases {
+		if got := restartBackoff(policy, c.failures); got != c.want {
+			t.Errorf("restartBackoff(policy, %d) = %s, want %s", c.failures, got, c.want)
+		}
+	}
+}